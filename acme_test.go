@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAcmeDomains(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want []string
+	}{
+		{name: "unset", env: "", want: nil},
+		{name: "single domain", env: "example.com", want: []string{"example.com"}},
+		{
+			name: "multiple domains with whitespace",
+			env:  "example.com, www.example.com ,api.example.com",
+			want: []string{"example.com", "www.example.com", "api.example.com"},
+		},
+		{name: "trailing comma", env: "example.com,", want: []string{"example.com"}},
+		{name: "only commas and whitespace", env: " , ,", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ACME_DOMAINS", tt.env)
+			if got := acmeDomains(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("acmeDomains() with ACME_DOMAINS=%q = %#v, want %#v", tt.env, got, tt.want)
+			}
+		})
+	}
+}
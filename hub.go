@@ -0,0 +1,376 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsSendBuffer  = 16 // per-connection outbound buffer before we consider it a slow consumer
+	wsPingPeriod  = 30 * time.Second
+	wsPongTimeout = 60 * time.Second
+	wsWriteWait   = 10 * time.Second
+
+	// wsReplayBufCap bounds how many live broadcasts deliver will queue
+	// while a client's backlog replay is still in flight. A client whose
+	// replay is taking long enough to fill this is exactly the slow
+	// consumer trySend already drops once replay is over, so the cap
+	// matches wsSendBuffer rather than growing unbounded for as long as
+	// replay lasts.
+	wsReplayBufCap = wsSendBuffer
+)
+
+// wsClient is one subscriber of the live feed.
+type wsClient struct {
+	conn *websocket.Conn
+	send chan Message
+
+	// done is closed exactly once, when c is torn down (disconnect or a
+	// slow-consumer drop). send is never closed - every sender selects on
+	// done alongside the send itself instead, so a send can never race a
+	// concurrent close of the channel it's sending on.
+	done     chan struct{}
+	doneOnce sync.Once
+
+	// replayedThrough is the highest Message.seq already delivered to this
+	// client via its initial backlog replay (0 if it didn't request one).
+	// Broadcast skips anything at or before it, so a post that lands while
+	// the backlog is still being read isn't delivered twice - once from the
+	// snapshot, once live. This has to be the server-assigned seq rather
+	// than Timestamp: Timestamp is client-supplied and two posts can share
+	// one, which would wrongly skip a later message with the same value.
+	replayedThrough int64
+
+	// bufMu guards replaying and replayBuf. c is registered with the hub
+	// before its backlog replay begins (so a post landing mid-replay can
+	// never be dropped for arriving before registration), but while
+	// replaying is true, Hub.Run's broadcast case buffers new messages into
+	// replayBuf instead of handing them to send directly - otherwise a live
+	// post could be interleaved into the middle of backlog delivery, which
+	// is still in progress on another goroutine. serveStream flips
+	// replaying off and flushes replayBuf, in arrival order, once the
+	// backlog is fully drained.
+	bufMu     sync.Mutex
+	replaying bool
+	replayBuf []Message
+}
+
+// deliver routes m to c: while c's backlog replay is still in flight, m is
+// queued in replayBuf instead of sent, so it can't overtake backlog entries
+// still being pushed; serveStream drains the queue, in arrival order, once
+// replay finishes (see drainReplay). Once replay is done, m goes straight
+// to send via trySend as before. Returns false if c should be dropped as a
+// slow consumer - either the usual full-send-buffer case, or a replay that
+// has been stuck long enough to fill replayBuf.
+func (c *wsClient) deliver(m Message) bool {
+	c.bufMu.Lock()
+	if c.replaying {
+		if len(c.replayBuf) >= wsReplayBufCap {
+			c.bufMu.Unlock()
+			return false
+		}
+		c.replayBuf = append(c.replayBuf, m)
+		c.bufMu.Unlock()
+		return true
+	}
+	c.bufMu.Unlock()
+	return c.trySend(m)
+}
+
+// drainReplay returns the next batch of live messages queued in replayBuf
+// while c's backlog replay was in flight. If the buffer is empty, it marks
+// replay finished (so deliver starts sending straight to send again) and
+// reports done=true. Otherwise it clears the batch it returns but leaves
+// replaying set, since more may have arrived by the time the caller has
+// pushed this batch out - the caller must keep calling drainReplay until
+// done is true, finishing only once a call observes nothing left to flush.
+// This handshake (rather than a single snapshot-then-flip) is what keeps
+// deliver and the flush atomic with respect to each other: there's no
+// instant where replaying is false while buffered messages are still
+// waiting to be pushed, which would let a fresh broadcast (sent straight to
+// send by deliver) overtake them.
+func (c *wsClient) drainReplay() (batch []Message, done bool) {
+	c.bufMu.Lock()
+	defer c.bufMu.Unlock()
+	if len(c.replayBuf) == 0 {
+		c.replaying = false
+		return nil, true
+	}
+	batch, c.replayBuf = c.replayBuf, nil
+	return batch, false
+}
+
+// shutdown marks c done. Safe to call more than once (e.g. once from a
+// slow-consumer drop and again from unregister).
+func (c *wsClient) shutdown() {
+	c.doneOnce.Do(func() { close(c.done) })
+}
+
+// trySend delivers m to c without blocking: if c's buffer is full or c is
+// already done, it's dropped rather than stalling the caller. Used by
+// Hub.Run's broadcast fan-out, where one stuck subscriber must never block
+// delivery to every other one.
+func (c *wsClient) trySend(m Message) bool {
+	select {
+	case c.send <- m:
+		return true
+	case <-c.done:
+		return false
+	default:
+		return false
+	}
+}
+
+// sendBlocking delivers m to c, waiting for buffer space if necessary, but
+// gives up the moment c is done instead of assuming send stays viable for
+// as long as the caller keeps feeding it. Used by serveStream's backlog
+// replay, which - unlike a live broadcast - really does want to wait for a
+// slow-but-still-connected client rather than drop its history.
+func (c *wsClient) sendBlocking(m Message) bool {
+	select {
+	case c.send <- m:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// Hub fans out newly-posted messages to every connected /api/stream
+// subscriber. A client whose send buffer fills (it's reading too slowly) is
+// dropped rather than letting it stall the broadcaster.
+type Hub struct {
+	mu         sync.Mutex
+	clients    map[*wsClient]bool
+	register   chan *wsClient
+	unregister chan *wsClient
+	broadcast  chan Message
+}
+
+// NewHub creates a Hub. Call Run in its own goroutine to start it.
+func NewHub() *Hub {
+	return &Hub{
+		clients:    make(map[*wsClient]bool),
+		register:   make(chan *wsClient),
+		unregister: make(chan *wsClient),
+		broadcast:  make(chan Message, 64),
+	}
+}
+
+// Run processes register/unregister/broadcast events until stop is closed.
+func (h *Hub) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case c := <-h.register:
+			h.mu.Lock()
+			h.clients[c] = true
+			h.mu.Unlock()
+
+		case c := <-h.unregister:
+			h.mu.Lock()
+			delete(h.clients, c)
+			h.mu.Unlock()
+			c.shutdown()
+
+		case m := <-h.broadcast:
+			h.mu.Lock()
+			for c := range h.clients {
+				if m.seq <= c.replayedThrough {
+					// Already delivered via this client's backlog replay.
+					continue
+				}
+				if !c.deliver(m) {
+					delete(h.clients, c)
+					c.shutdown()
+				}
+			}
+			h.mu.Unlock()
+
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Broadcast fans m out to every currently-connected subscriber.
+func (h *Hub) Broadcast(m Message) {
+	h.broadcast <- m
+}
+
+// checkOrigin matches the CORS policy enforced on the HTTP API: only the
+// configured allowedOrigin (or no Origin header, e.g. non-browser clients)
+// may open a stream.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || origin == allowedOrigin
+}
+
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: checkOrigin,
+}
+
+// serveStream upgrades to a WebSocket, optionally replays messages newer
+// than ?since=<ts> (unix millis) from the store, then streams every
+// subsequently-posted message live until the client disconnects.
+func serveStream(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		if !checkOrigin(r) {
+			http.Error(w, "origin not allowed", 403)
+			return
+		}
+
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		client := &wsClient{conn: conn, send: make(chan Message, wsSendBuffer), done: make(chan struct{}), replaying: true}
+
+		var since int64
+		if q := r.URL.Query().Get("since"); q != "" {
+			since, _ = strconv.ParseInt(q, 10, 64)
+		}
+
+		// Snapshot the backlog and register with the hub as one step under
+		// mu, so no poster can slip a message in between: otherwise a
+		// message appended (and broadcast) after the snapshot but before
+		// registration would land while this client is in neither the
+		// snapshot nor hub.clients, and Hub.Run's broadcast case would
+		// never find it to deliver - silently dropped for good. hub.register
+		// is unbuffered, so this blocks until Hub.Run has actually added
+		// client to its map before mu is released.
+		var backlog []Message
+		mu.RLock()
+		if since > 0 {
+			// messages is newest-first; emit the backlog oldest-first so
+			// the client's feed stays chronological.
+			for _, m := range messages {
+				if m.Timestamp > since {
+					backlog = append(backlog, m)
+				}
+			}
+			if len(messages) > 0 {
+				client.replayedThrough = messages[0].seq
+			}
+		}
+		hub.register <- client
+		mu.RUnlock()
+
+		// Start the pumps before feeding any backlog into client.send: the
+		// channel is only wsSendBuffer deep, so a backlog larger than that
+		// would otherwise block this handler goroutine forever with nothing
+		// draining it.
+		go client.writePump(hub)
+		go client.readPump(hub)
+
+		// sendBlocking waits for buffer space rather than dropping, since the
+		// backlog is history the client asked for, not a best-effort live
+		// update - but it gives up the moment the client disconnects instead
+		// of assuming send stays open for the rest of this loop. client is
+		// already registered at this point, so anything broadcast while
+		// this loop is still draining goes into client.replayBuf (see
+		// deliver) instead of being lost or interleaved ahead of backlog
+		// entries still waiting their turn.
+		disconnected := false
+		for i := len(backlog) - 1; i >= 0; i-- {
+			if !client.sendBlocking(backlog[i]) {
+				disconnected = true
+				break
+			}
+		}
+
+		// Flush whatever landed in replayBuf while the backlog above was
+		// still draining, in the order it was broadcast. drainReplay only
+		// reports done once a call finds nothing left to flush, so a
+		// message that arrives while this very loop is pushing an earlier
+		// batch still gets picked up by the next iteration, rather than
+		// racing a switch back to direct delivery while it's in flight.
+		for !disconnected {
+			batch, done := client.drainReplay()
+			for _, m := range batch {
+				if !client.sendBlocking(m) {
+					disconnected = true
+					break
+				}
+			}
+			if done {
+				break
+			}
+		}
+	}
+}
+
+// readPump discards incoming client frames (this is a one-way feed) and
+// keeps the pong deadline fresh until the connection closes.
+func (c *wsClient) readPump(hub *Hub) {
+	defer func() {
+		hub.unregister <- c
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		return nil
+	})
+
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump delivers broadcast messages and periodic pings to the client.
+func (c *wsClient) writePump(hub *Hub) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case m := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(m); err != nil {
+				return
+			}
+
+		case <-c.done:
+			// Flush whatever was already queued before c was torn down -
+			// send is never closed, so draining it here (rather than relying
+			// on a closed-channel read) is the only way to avoid silently
+			// dropping messages that made it into the buffer before the
+			// disconnect.
+		drain:
+			for {
+				select {
+				case m := <-c.send:
+					c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+					if err := c.conn.WriteJSON(m); err != nil {
+						return
+					}
+				default:
+					break drain
+				}
+			}
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHubBroadcastSkipsMessagesAlreadyReplayed covers the double-delivery
+// race from serveStream: a post that lands between a client's backlog
+// snapshot and its registration would otherwise reach the client twice -
+// once via the snapshot, once via this broadcast. replayedThrough is the
+// guard against that; this exercises it directly, without needing to win a
+// real HTTP/websocket timing race.
+func TestHubBroadcastSkipsMessagesAlreadyReplayed(t *testing.T) {
+	hub := NewHub()
+	stop := make(chan struct{})
+	go hub.Run(stop)
+	defer close(stop)
+
+	client := &wsClient{send: make(chan Message, wsSendBuffer), done: make(chan struct{})}
+	client.replayedThrough = 100
+	hub.register <- client
+
+	hub.Broadcast(Message{Content: "already in backlog", seq: 100})
+	hub.Broadcast(Message{Content: "new since backlog", seq: 101})
+
+	select {
+	case m := <-client.send:
+		if m.Content != "new since backlog" {
+			t.Fatalf("got %q, want only the one newer than replayedThrough", m.Content)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the post newer than replayedThrough")
+	}
+
+	select {
+	case m := <-client.send:
+		t.Fatalf("received a second message %+v; the one at-or-before replayedThrough should have been skipped", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestHubBroadcastUsesSeqNotTimestampForDedup guards against regressing the
+// cutoff back to Message.Timestamp: Timestamp is client-supplied, so two
+// posts from different authors can share one. If the dedup compared
+// Timestamps, a genuinely new message with the same Timestamp as the
+// backlog cutoff would be wrongly dropped instead of delivered.
+func TestHubBroadcastUsesSeqNotTimestampForDedup(t *testing.T) {
+	hub := NewHub()
+	stop := make(chan struct{})
+	go hub.Run(stop)
+	defer close(stop)
+
+	client := &wsClient{send: make(chan Message, wsSendBuffer), done: make(chan struct{})}
+	client.replayedThrough = 100 // seq of the last backlog message
+	hub.register <- client
+
+	// Same Timestamp as whatever produced replayedThrough, but a strictly
+	// greater seq: this is a message that did not exist at snapshot time
+	// and must still be delivered.
+	hub.Broadcast(Message{Content: "new post, collided timestamp", Timestamp: 1234, seq: 101})
+
+	select {
+	case m := <-client.send:
+		if m.Content != "new post, collided timestamp" {
+			t.Fatalf("got %q, want the new post", m.Content)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("new message with a Timestamp colliding with the backlog cutoff was dropped")
+	}
+}
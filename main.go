@@ -1,8 +1,10 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -13,11 +15,16 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	mldsa44 "github.com/cloudflare/circl/sign/mldsa/mldsa44"
 	mldsa65 "github.com/cloudflare/circl/sign/mldsa/mldsa65"
 	mldsa87 "github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/Marqui-13/pq-guestbook/ratelimit"
+	"github.com/Marqui-13/pq-guestbook/store"
 )
 
 type Message struct {
@@ -30,47 +37,245 @@ type Message struct {
 	Browser   string `json:"browser"`  // e.g. "Chrome 120"
 	Platform  string `json:"platform"` // e.g. "Windows 10"
 	UserAgent string `json:"ua"`       // optional full UA
+
+	// seq is a server-assigned, strictly-increasing sequence number used
+	// internally to de-dup stream delivery (see wsClient.replayedThrough in
+	// hub.go). Unlike Timestamp, which is client-supplied and can collide
+	// across concurrent posters, seq is unique per accepted message, so it's
+	// safe to use as a delivery cutoff. It's unexported and never marshaled.
+	seq int64
 }
 
 var (
 	messages []Message
 	mu       sync.RWMutex
-	replayMu sync.Mutex
-	seen     = make(map[string]map[int64]bool)
-	rateMu     sync.Mutex
-	rateLimits = make(map[string]*rateInfo)
-)
 
-type rateInfo struct {
-	Tokens     float64
-	LastRefill time.Time
-}
+	// messageSeq hands out the next value for Message.seq. Guarded by mu,
+	// same as messages.
+	messageSeq int64
+	replayMu   sync.Mutex
+	seen       = make(map[string]map[int64]bool)
+
+	messageStore store.Store
+	rateLimiter  ratelimit.Limiter
+
+	// allowedOrigin is the CORS origin accepted by setSecurityHeaders. It
+	// defaults to the legacy Fly.io origin and is overridden in main() from
+	// ACME_DOMAINS when autocert is configured.
+	allowedOrigin = "https://pq-guestbook.fly.dev"
+)
 
 // Device-ID HMAC, modify if testing locally
 var rateLimitSecret = []byte(os.Getenv("RATE_LIMIT_SECRET"))
 
+// openRateLimiter selects a backend from RATE_LIMIT_BACKEND ("memory" or
+// "redis", default "memory"). The redis backend reads REDIS_ADDR (required),
+// REDIS_PASSWORD and REDIS_DB, and is what lets multiple Fly instances share
+// one set of buckets instead of each enforcing its own.
+func openRateLimiter() (ratelimit.Limiter, error) {
+	switch os.Getenv("RATE_LIMIT_BACKEND") {
+	case "redis":
+		addr := os.Getenv("REDIS_ADDR")
+		if addr == "" {
+			return nil, fmt.Errorf("REDIS_ADDR is required for RATE_LIMIT_BACKEND=redis")
+		}
+		db := 0
+		if v := os.Getenv("REDIS_DB"); v != "" {
+			if _, err := fmt.Sscanf(v, "%d", &db); err != nil {
+				return nil, fmt.Errorf("invalid REDIS_DB %q: %w", v, err)
+			}
+		}
+		return ratelimit.NewRedisLimiter(addr, os.Getenv("REDIS_PASSWORD"), db, "pq-guestbook:ratelimit:")
+	case "", "memory":
+		return ratelimit.NewMemoryLimiter(), nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_BACKEND %q", os.Getenv("RATE_LIMIT_BACKEND"))
+	}
+}
+
+// rateLimitKey combines the UA-derived device ID with a hash of the posting
+// pubkey, so rotating User-Agent strings alone can't let one attacker evade
+// the limit by looking like a fresh device each time.
+func rateLimitKey(deviceID, pubkey string) string {
+	sum := sha256.Sum256([]byte(pubkey))
+	return deviceID + ":" + hex.EncodeToString(sum[:8])
+}
+
 func canonicalPayload(author, content string, ts int64) []byte {
 	// Prevents injection or mismatched signing order
 	return []byte(fmt.Sprintf("%s\n%s\n%d", author, content, ts))
 }
 
-// Returns true if this pubkey+timestamp combo has been seen before
+// openStore selects a persistence backend from STORE_BACKEND ("fs" or
+// "sqlite", default "fs") and opens it at STORE_PATH (default
+// "guestbook.log" / "guestbook.db").
+func openStore() (store.Store, error) {
+	backend := os.Getenv("STORE_BACKEND")
+	switch backend {
+	case "sqlite":
+		path := os.Getenv("STORE_PATH")
+		if path == "" {
+			path = "guestbook.db"
+		}
+		return store.NewSQLiteStore(path)
+	case "", "fs":
+		path := os.Getenv("STORE_PATH")
+		if path == "" {
+			path = "guestbook.log"
+		}
+		return store.NewFileStore(path)
+	default:
+		return nil, fmt.Errorf("unknown STORE_BACKEND %q", backend)
+	}
+}
+
+// entryToMessage reconstructs a Message from a stored Entry. Author, Content
+// and Timestamp are read directly off the Entry rather than re-split out of
+// Payload, since the canonical encoding isn't unambiguously reversible when
+// Content contains a newline.
+func entryToMessage(e store.Entry) (Message, error) {
+	return Message{
+		Author:    e.Author,
+		Content:   e.Content,
+		Timestamp: e.Timestamp,
+		Algo:      e.Algo,
+		Signature: e.Sig,
+		PubKey:    e.PubKey,
+	}, nil
+}
+
+// loadAndVerifyLog replays the full hash chain from s, re-verifies every
+// ML-DSA signature over its recorded payload, and confirms the chain links.
+// It refuses (returns an error) the moment either check fails, and populates
+// the in-memory messages slice (newest first, matching /api/post's order) on
+// success.
+func loadAndVerifyLog(s store.Store) error {
+	entries, err := s.Replay()
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+
+	if _, err := store.VerifyChain(entries); err != nil {
+		return err
+	}
+
+	restored := make([]Message, 0, len(entries))
+	for _, e := range entries {
+		m, err := entryToMessage(e)
+		if err != nil {
+			return err
+		}
+
+		valid, err := verifyMessage(m, e.Payload)
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", e.Index, err)
+		}
+		if !valid {
+			return fmt.Errorf("entry %d: signature no longer verifies", e.Index)
+		}
+
+		restored = append(restored, m)
+	}
+
+	mu.Lock()
+	messages = nil
+	messageSeq = 0
+	for i := len(restored) - 1; i >= 0; i-- {
+		messageSeq++
+		m := restored[i]
+		m.seq = messageSeq
+		messages = append(messages, m)
+	}
+	mu.Unlock()
+
+	return nil
+}
+
+// freshnessWindowMs is how far a message's ts may drift from server time
+// (±15s) before /api/post rejects it as stale. Entries older than twice this
+// can never legitimately match again, which bounds how long replaySweep has
+// to retain them.
+const freshnessWindowMs = 15000
+
+// maxSeenPerPubkey caps how many in-flight timestamps a single pubkey may
+// occupy in seen. Within the freshness window a legitimate client posts at
+// most a handful of messages, so hitting this cap means either abuse or a
+// broken clock; either way the extra timestamp is rejected.
+const maxSeenPerPubkey = 64
+
+// replayRejectedTotal backs the replay_rejected_total /metrics counter.
+var replayRejectedTotal int64
+
+// replaySeen returns true if this pubkey+timestamp combo has been seen
+// before, or if pubkey's in-flight timestamp count already hit
+// maxSeenPerPubkey (treated the same as a replay: reject).
 func replaySeen(pubkey []byte, ts int64) bool {
 	replayMu.Lock()
 	defer replayMu.Unlock()
 
 	key := hex.EncodeToString(pubkey)
-	if _, ok := seen[key]; !ok {
-		seen[key] = make(map[int64]bool)
+	bucket, ok := seen[key]
+	if !ok {
+		bucket = make(map[int64]bool)
+		seen[key] = bucket
 	}
 
-	if seen[key][ts] {
+	if bucket[ts] {
+		atomic.AddInt64(&replayRejectedTotal, 1)
 		return true
 	}
-	seen[key][ts] = true
+	if len(bucket) >= maxSeenPerPubkey {
+		atomic.AddInt64(&replayRejectedTotal, 1)
+		return true
+	}
+
+	bucket[ts] = true
 	return false
 }
 
+// replayCacheEntries returns the total number of in-flight timestamps held
+// across every pubkey in seen, for the /metrics gauge.
+func replayCacheEntries() int {
+	replayMu.Lock()
+	defer replayMu.Unlock()
+
+	n := 0
+	for _, bucket := range seen {
+		n += len(bucket)
+	}
+	return n
+}
+
+// sweepReplayCache periodically removes timestamps outside the freshness
+// window from seen - they can never legitimately match again - and deletes
+// any pubkey whose bucket becomes empty, so the cache can't grow
+// unboundedly over the life of the process.
+func sweepReplayCache(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now().UnixMilli()
+			replayMu.Lock()
+			for pubkey, bucket := range seen {
+				for ts := range bucket {
+					if drift := now - ts; drift < -freshnessWindowMs || drift > freshnessWindowMs {
+						delete(bucket, ts)
+					}
+				}
+				if len(bucket) == 0 {
+					delete(seen, pubkey)
+				}
+			}
+			replayMu.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
 func deviceIDFromUA(ua string) string {
 	mac := hmac.New(sha256.New, rateLimitSecret)
 	mac.Write([]byte(ua))
@@ -78,40 +283,169 @@ func deviceIDFromUA(ua string) string {
 	return hex.EncodeToString(sum[:8]) // 16-char stable pseudonym
 }
 
-const (
-	maxTokens  = 8    // Burst
-	refillRate = 0.25 // tokens/sec (1 request every 4s sustained)
-)
+// verifyMLDSA checks sig over msg using whichever ML-DSA level matches
+// pubBytes' length. It returns an error (suitable for http.Error) for a
+// malformed or unsupported pubkey, distinct from an ordinary verify failure.
+func verifyMLDSA(pubBytes, msg, sigBytes []byte) (bool, error) {
+	switch len(pubBytes) {
+	case mldsa44.PublicKeySize:
+		var pub mldsa44.PublicKey
+		if pub.UnmarshalBinary(pubBytes) != nil {
+			return false, fmt.Errorf("invalid pubkey")
+		}
+		return mldsa44.Verify(&pub, msg, nil, sigBytes), nil
 
-func allowDevice(id string) bool {
-	rateMu.Lock()
-	defer rateMu.Unlock()
+	case mldsa65.PublicKeySize:
+		var pub mldsa65.PublicKey
+		if pub.UnmarshalBinary(pubBytes) != nil {
+			return false, fmt.Errorf("invalid pubkey")
+		}
+		return mldsa65.Verify(&pub, msg, nil, sigBytes), nil
 
-	now := time.Now()
-	ri, ok := rateLimits[id]
-	if !ok {
-		rateLimits[id] = &rateInfo{
-			Tokens:     maxTokens,
-			LastRefill: now,
+	case mldsa87.PublicKeySize:
+		var pub mldsa87.PublicKey
+		if pub.UnmarshalBinary(pubBytes) != nil {
+			return false, fmt.Errorf("invalid pubkey")
 		}
-		return true
+		return mldsa87.Verify(&pub, msg, nil, sigBytes), nil
+
+	default:
+		return false, fmt.Errorf("unsupported ML-DSA level")
+	}
+}
+
+// hybridLevels are the ML-DSA levels usable in an "ed25519+<level>" hybrid
+// Algo.
+var hybridLevels = map[string]bool{"mldsa44": true, "mldsa65": true, "mldsa87": true}
+
+// decodeSized base64-decodes s and rejects anything implausibly large,
+// mirroring the size guard /api/post applies to pure ML-DSA keys/sigs.
+func decodeSized(s string) ([]byte, error) {
+	b, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil || len(b) > 5000 {
+		return nil, fmt.Errorf("invalid encoding")
 	}
+	return b, nil
+}
 
-	elapsed := now.Sub(ri.LastRefill).Seconds()
-	ri.LastRefill = now
+// verifyHybrid checks a "classical.pq" envelope (PubKey and Signature each a
+// dot-joined pair of base64 fields) requiring both the Ed25519 signature and
+// the ML-DSA signature named by algo ("ed25519+mldsa44/65/87") to verify.
+func verifyHybrid(algo, pubField, sigField string, canonical []byte) (bool, error) {
+	level := strings.TrimPrefix(algo, "ed25519+")
+	if !hybridLevels[level] {
+		return false, fmt.Errorf("unsupported hybrid algo %q", algo)
+	}
 
-	ri.Tokens = math.Min(maxTokens, ri.Tokens+elapsed*refillRate)
-	if ri.Tokens < 1 {
-		return false
+	pubParts := strings.SplitN(pubField, ".", 2)
+	sigParts := strings.SplitN(sigField, ".", 2)
+	if len(pubParts) != 2 || len(sigParts) != 2 {
+		return false, fmt.Errorf("hybrid pubkey/signature must be classical.pq")
 	}
 
-	ri.Tokens -= 1
-	return true
+	classicalPub, err := decodeSized(pubParts[0])
+	if err != nil || len(classicalPub) != ed25519.PublicKeySize {
+		return false, fmt.Errorf("invalid ed25519 pubkey")
+	}
+	classicalSig, err := decodeSized(sigParts[0])
+	if err != nil || len(classicalSig) != ed25519.SignatureSize {
+		return false, fmt.Errorf("invalid ed25519 signature")
+	}
+	pqPub, err := decodeSized(pubParts[1])
+	if err != nil {
+		return false, fmt.Errorf("invalid ML-DSA pubkey")
+	}
+	pqSig, err := decodeSized(sigParts[1])
+	if err != nil {
+		return false, fmt.Errorf("invalid ML-DSA signature")
+	}
+
+	pqValid, err := verifyMLDSA(pqPub, canonical, pqSig)
+	if err != nil {
+		return false, err
+	}
+
+	classicalValid := ed25519.Verify(classicalPub, canonical, classicalSig)
+	return classicalValid && pqValid, nil
+}
+
+// verifyMessage dispatches to pure ML-DSA or, for an "ed25519+<level>" Algo,
+// hybrid classical+PQ verification.
+func verifyMessage(m Message, canonical []byte) (bool, error) {
+	if strings.HasPrefix(m.Algo, "ed25519+") {
+		return verifyHybrid(m.Algo, m.PubKey, m.Signature, canonical)
+	}
+
+	pubBytes, err := decodeSized(m.PubKey)
+	if err != nil {
+		return false, fmt.Errorf("invalid pubkey")
+	}
+	sigBytes, err := decodeSized(m.Signature)
+	if err != nil {
+		return false, fmt.Errorf("invalid signature")
+	}
+	return verifyMLDSA(pubBytes, canonical, sigBytes)
+}
+
+// acmeDomains parses ACME_DOMAINS (comma-separated) into a slice, or nil if
+// unset - the signal that autocert/TLS should be skipped in favor of plain
+// HTTP, e.g. for local development.
+func acmeDomains() []string {
+	raw := strings.TrimSpace(os.Getenv("ACME_DOMAINS"))
+	if raw == "" {
+		return nil
+	}
+	var domains []string
+	for _, d := range strings.Split(raw, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// serveTLS runs the HTTPS listener on :443 backed by an autocert.Manager for
+// domains, plus a parallel :80 listener that answers ACME HTTP-01 challenges
+// and 301-redirects everything else to HTTPS.
+func serveTLS(domains []string) error {
+	cacheDir := os.Getenv("ACME_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = "acme-cache"
+	}
+
+	certManager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      os.Getenv("ACME_EMAIL"),
+	}
+
+	go func() {
+		redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			target := "https://" + r.Host + r.URL.RequestURI()
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		})
+		httpServer := &http.Server{
+			Addr:    ":80",
+			Handler: certManager.HTTPHandler(redirect),
+		}
+		if err := httpServer.ListenAndServe(); err != nil {
+			log.Printf("ACME HTTP-01 / redirect listener stopped: %v", err)
+		}
+	}()
+
+	tlsServer := &http.Server{
+		Addr: ":443",
+		TLSConfig: &tls.Config{
+			GetCertificate: certManager.GetCertificate,
+		},
+	}
+	return tlsServer.ListenAndServeTLS("", "")
 }
 
 func setSecurityHeaders(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "https://pq-guestbook.fly.dev")
+	w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, User-Agent")
 
@@ -123,11 +457,83 @@ func setSecurityHeaders(w http.ResponseWriter) {
 }
 
 func main() {
+	// Open the tamper-evident log and replay it before serving anything. A
+	// broken chain or a signature that no longer verifies means the
+	// underlying storage was tampered with, so refuse to start.
+	s, err := openStore()
+	if err != nil {
+		log.Fatalf("store: %v", err)
+	}
+	if err := loadAndVerifyLog(s); err != nil {
+		log.Fatalf("refusing to serve - tamper-evident log failed verification: %v", err)
+	}
+	messageStore = s
+	defer messageStore.Close()
+
+	rl, err := openRateLimiter()
+	if err != nil {
+		log.Fatalf("rate limiter: %v", err)
+	}
+	rateLimiter = rl
+	defer rateLimiter.Close()
+
+	replaySweepStop := make(chan struct{})
+	go sweepReplayCache(30*time.Second, replaySweepStop)
+	defer close(replaySweepStop)
+
+	hub := NewHub()
+	hubStop := make(chan struct{})
+	go hub.Run(hubStop)
+	defer close(hubStop)
+
+	registerRoutes(http.DefaultServeMux, hub)
+
+	// Ensure RATE_LIMIT_SECRET is set
+	secret := os.Getenv("RATE_LIMIT_SECRET")
+	if secret == "" {
+		log.Fatal("RATE_LIMIT_SECRET is not set. Set it using `fly secrets set RATE_LIMIT_SECRET=$(openssl rand -hex 32)`")
+	}
+
+	// Decode hex → bytes (Fly secrets store raw strings)
+	secretBytes, err := hex.DecodeString(secret)
+	if err != nil {
+		log.Fatalf("RATE_LIMIT_SECRET must be a 32-byte hex string: %v", err)
+	}
+
+	if len(secretBytes) != 32 {
+		log.Fatalf("RATE_LIMIT_SECRET must decode to exactly 32 bytes, got %d bytes", len(secretBytes))
+	}
+
+	rateLimitSecret = secretBytes
+
+	// With ACME domains configured, derive the CORS origin from them and
+	// serve HTTPS (with an HTTP->HTTPS redirector) instead of plain HTTP.
+	if domains := acmeDomains(); len(domains) > 0 {
+		allowedOrigin = "https://" + domains[0]
+		log.Println("⚛️ Post-Quantum Guestbook live on :443 via ACME for", domains)
+		log.Fatal(serveTLS(domains))
+		return
+	}
+
+	// Start server
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Println("⚛️ Post-Quantum Guestbook live on :" + port + " (real ML-DSA browser signing)")
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
+
+// registerRoutes wires every HTTP/WebSocket handler onto mux. Split out from
+// main so tests can register the same routes on an isolated ServeMux
+// against a test store/rate-limiter, without needing to run the real
+// server's startup (ACME, secrets) sequence.
+func registerRoutes(mux *http.ServeMux, hub *Hub) {
 	// Serve static frontend
-	http.Handle("/", http.FileServer(http.Dir("static")))
+	mux.Handle("/", http.FileServer(http.Dir("static")))
 
 	// API: get all messages
-	http.HandleFunc("/api/messages", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/messages", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
 			http.Error(w, "GET only", 405)
 			return
@@ -145,7 +551,7 @@ func main() {
 	)
 
 	// API: post new message with pure ML-DSA signature
-	http.HandleFunc("/api/post", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/post", func(w http.ResponseWriter, r *http.Request) {
 		setSecurityHeaders(w)
 
 		if r.Method == http.MethodOptions {
@@ -180,14 +586,13 @@ func main() {
 			return
 		}
 
-		// Decode keys and signature
-		pubBytes, err := base64.RawStdEncoding.DecodeString(m.PubKey)
-		if err != nil || len(pubBytes) > 5000 {
+		// Basic shape guard on the raw key/sig fields; verifyMessage does the
+		// real (algo-aware) decoding below.
+		if len(m.PubKey) > 10000 {
 			http.Error(w, "invalid pubkey", 400)
 			return
 		}
-		sigBytes, err := base64.RawStdEncoding.DecodeString(m.Signature)
-		if err != nil || len(sigBytes) > 5000 {
+		if len(m.Signature) > 10000 {
 			http.Error(w, "invalid signature", 400)
 			return
 		}
@@ -195,20 +600,28 @@ func main() {
 		// Timestamp replay prevention (±15s)
 		now := time.Now().UnixMilli()
 		drift := now - m.Timestamp
-		if drift < -15000 || drift > 15000 {
+		if drift < -freshnessWindowMs || drift > freshnessWindowMs {
 			http.Error(w, "timestamp not fresh", 401)
 			return
 		}
 
 		// Per-pubkey replay detection
-		if replaySeen(pubBytes, m.Timestamp) {
+		if replaySeen([]byte(m.PubKey), m.Timestamp) {
 			http.Error(w, "replay detected", 401)
 			return
 		}
 
-		// Device rate limit (UA fingerprint)
+		// Device rate limit (UA fingerprint + pubkey, so rotating UA alone
+		// can't evade it)
 		deviceID := deviceIDFromUA(m.UserAgent)
-		if !allowDevice(deviceID) {
+		allowed, retryAfter, err := rateLimiter.Allow(rateLimitKey(deviceID, m.PubKey))
+		if err != nil {
+			log.Printf("rate limiter error: %v", err)
+			http.Error(w, "rate limiter unavailable", 503)
+			return
+		}
+		if !allowed {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retryAfter.Seconds()))))
 			http.Error(w, "rate limit exceeded", 429)
 			return
 		}
@@ -216,75 +629,102 @@ func main() {
 		// Canonical message format
 		canonical := canonicalPayload(m.Author, m.Content, m.Timestamp)
 
-		// ML-DSA verify
-		var valid bool
-		switch len(pubBytes) {
-		case mldsa44.PublicKeySize:
-			var pub mldsa44.PublicKey
-			if pub.UnmarshalBinary(pubBytes) != nil {
-				http.Error(w, "invalid pubkey", 400)
-				return
-			}
-			valid = mldsa44.Verify(&pub, canonical, nil, sigBytes)
-
-		case mldsa65.PublicKeySize:
-			var pub mldsa65.PublicKey
-			if pub.UnmarshalBinary(pubBytes) != nil {
-				http.Error(w, "invalid pubkey", 400)
-				return
-			}
-			valid = mldsa65.Verify(&pub, canonical, nil, sigBytes)
-
-		case mldsa87.PublicKeySize:
-			var pub mldsa87.PublicKey
-			if pub.UnmarshalBinary(pubBytes) != nil {
-				http.Error(w, "invalid pubkey", 400)
-				return
-			}
-			valid = mldsa87.Verify(&pub, canonical, nil, sigBytes)
-
-		default:
-			http.Error(w, "unsupported ML-DSA level", 400)
+		// ML-DSA (or hybrid Ed25519+ML-DSA) verify
+		valid, err := verifyMessage(m, canonical)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
 			return
 		}
-
 		if !valid {
 			http.Error(w, "invalid ML-DSA signature", 401)
 			return
 		}
 
+		// Append to the tamper-evident log before serving it back, so every
+		// message returned to clients is already chained and durable.
+		if messageStore != nil {
+			if _, err := messageStore.Append(m.Author, m.Content, m.Timestamp, canonical, m.Algo, m.Signature, m.PubKey); err != nil {
+				log.Printf("store append failed: %v", err)
+				http.Error(w, "storage error", 500)
+				return
+			}
+		}
+
 		// Store messages
 		mu.Lock()
+		messageSeq++
+		m.seq = messageSeq
 		messages = append([]Message{m}, messages...)
 		mu.Unlock()
 
+		hub.Broadcast(m)
+
 		w.WriteHeader(200)
 		w.Write([]byte(`{"status":"quantum-safe post accepted"}`))
 	})
 
-	// Ensure RATE_LIMIT_SECRET is set
-	secret := os.Getenv("RATE_LIMIT_SECRET")
-	if secret == "" {
-		log.Fatal("RATE_LIMIT_SECRET is not set. Set it using `fly secrets set RATE_LIMIT_SECRET=$(openssl rand -hex 32)`")
-	}
-
-	// Decode hex → bytes (Fly secrets store raw strings)
-	secretBytes, err := hex.DecodeString(secret)
-	if err != nil {
-		log.Fatalf("RATE_LIMIT_SECRET must be a 32-byte hex string: %v", err)
-	}
+	// API: live feed of newly-posted messages over a WebSocket
+	mux.HandleFunc("/api/stream", serveStream(hub))
 
-	if len(secretBytes) != 32 {
-		log.Fatalf("RATE_LIMIT_SECRET must decode to exactly 32 bytes, got %d bytes", len(secretBytes))
-	}
+	// API: tamper-evidence audit endpoints
+	mux.HandleFunc("/api/verify", func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		if messageStore == nil {
+			http.Error(w, "persistence not enabled", 501)
+			return
+		}
+		head, length, err := messageStore.Head()
+		if err != nil {
+			http.Error(w, "store error", 500)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"head":   head,
+			"length": length,
+		})
+	})
 
-	rateLimitSecret = secretBytes
+	mux.HandleFunc("/api/proof", func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		if messageStore == nil {
+			http.Error(w, "persistence not enabled", 501)
+			return
+		}
+		var index uint64
+		if q := r.URL.Query().Get("index"); q != "" {
+			if _, err := fmt.Sscanf(q, "%d", &index); err != nil {
+				http.Error(w, "invalid index", 400)
+				return
+			}
+		}
+		proof, err := messageStore.Proof(index)
+		if err != nil {
+			http.Error(w, err.Error(), 404)
+			return
+		}
+		json.NewEncoder(w).Encode(proof)
+	})
 
-	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	log.Println("⚛️ Post-Quantum Guestbook live on :" + port + " (real ML-DSA browser signing)")
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	// Operator-facing Prometheus-style metrics for replay-cache abuse.
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", 405)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintf(w, "# HELP replay_rejected_total Total /api/post requests rejected as replays or replay-cache overflow.\n")
+		fmt.Fprintf(w, "# TYPE replay_rejected_total counter\n")
+		fmt.Fprintf(w, "replay_rejected_total %d\n", atomic.LoadInt64(&replayRejectedTotal))
+		fmt.Fprintf(w, "# HELP replay_cache_entries Current number of in-flight (pubkey, ts) entries held for replay detection.\n")
+		fmt.Fprintf(w, "# TYPE replay_cache_entries gauge\n")
+		fmt.Fprintf(w, "replay_cache_entries %d\n", replayCacheEntries())
+	})
 }
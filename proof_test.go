@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mldsa65 "github.com/cloudflare/circl/sign/mldsa/mldsa65"
+
+	"github.com/Marqui-13/pq-guestbook/ratelimit"
+	"github.com/Marqui-13/pq-guestbook/store"
+)
+
+// postSignedMessage posts one validly-signed message to server and fails the
+// test if it isn't accepted.
+func postSignedMessage(t *testing.T, serverURL, author, content, ua string) {
+	t.Helper()
+
+	pub, priv, err := mldsa65.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("mldsa65 keygen: %v", err)
+	}
+	pubBytes, err := pub.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal pubkey: %v", err)
+	}
+
+	ts := time.Now().UnixMilli()
+	sig, err := priv.Sign(rand.Reader, canonicalPayload(author, content, ts), crypto.Hash(0))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	body, err := json.Marshal(Message{
+		Author:    author,
+		Content:   content,
+		Timestamp: ts,
+		Algo:      "mldsa65",
+		PubKey:    base64.RawStdEncoding.EncodeToString(pubBytes),
+		Signature: base64.RawStdEncoding.EncodeToString(sig),
+		UserAgent: ua,
+	})
+	if err != nil {
+		t.Fatalf("marshal message: %v", err)
+	}
+
+	resp, err := http.Post(serverURL+"/api/post", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /api/post: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /api/post: status %d", resp.StatusCode)
+	}
+}
+
+// TestVerifyAndProofEndpointsReflectThePersistedChain posts a couple of
+// messages, then confirms /api/verify reports the resulting head/length and
+// /api/proof returns an audit path that re-verifies via store.VerifyChain.
+func TestVerifyAndProofEndpointsReflectThePersistedChain(t *testing.T) {
+	origMessages, origStore, origLimiter, origSecret := messages, messageStore, rateLimiter, rateLimitSecret
+	defer func() {
+		messages, messageStore, rateLimiter, rateLimitSecret = origMessages, origStore, origLimiter, origSecret
+	}()
+	messages = nil
+	rateLimitSecret = []byte("test-secret-not-for-production")
+
+	s, err := store.NewFileStore(filepath.Join(t.TempDir(), "guestbook.log"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	defer s.Close()
+	messageStore = s
+
+	rateLimiter = ratelimit.NewMemoryLimiter()
+	defer rateLimiter.Close()
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, NewHub())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	postSignedMessage(t, server.URL, "alice", "first entry", "proof-test-1")
+	postSignedMessage(t, server.URL, "bob", "second entry", "proof-test-2")
+
+	wantHead, wantLength, err := s.Head()
+	if err != nil {
+		t.Fatalf("Head: %v", err)
+	}
+
+	verifyResp, err := http.Get(server.URL + "/api/verify")
+	if err != nil {
+		t.Fatalf("GET /api/verify: %v", err)
+	}
+	defer verifyResp.Body.Close()
+	if verifyResp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /api/verify: status %d", verifyResp.StatusCode)
+	}
+	var verifyBody struct {
+		Head   string `json:"head"`
+		Length uint64 `json:"length"`
+	}
+	if err := json.NewDecoder(verifyResp.Body).Decode(&verifyBody); err != nil {
+		t.Fatalf("decode /api/verify body: %v", err)
+	}
+	if verifyBody.Head != wantHead || verifyBody.Length != wantLength {
+		t.Errorf("/api/verify = {%q %d}, want {%q %d}", verifyBody.Head, verifyBody.Length, wantHead, wantLength)
+	}
+
+	proofResp, err := http.Get(server.URL + "/api/proof?index=0")
+	if err != nil {
+		t.Fatalf("GET /api/proof: %v", err)
+	}
+	defer proofResp.Body.Close()
+	if proofResp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /api/proof: status %d", proofResp.StatusCode)
+	}
+	var proof []store.Entry
+	if err := json.NewDecoder(proofResp.Body).Decode(&proof); err != nil {
+		t.Fatalf("decode /api/proof body: %v", err)
+	}
+	if len(proof) != int(wantLength) {
+		t.Fatalf("/api/proof?index=0 returned %d entries, want %d", len(proof), wantLength)
+	}
+	head, err := store.VerifyChain(proof)
+	if err != nil {
+		t.Fatalf("VerifyChain(proof) = %v, want nil", err)
+	}
+	if head != wantHead {
+		t.Errorf("VerifyChain(proof) head = %q, want %q", head, wantHead)
+	}
+}
+
+// TestProofEndpointRejectsOutOfRangeIndex confirms an index beyond the
+// chain's length is reported as a client error rather than a crash or a
+// silently-empty body.
+func TestProofEndpointRejectsOutOfRangeIndex(t *testing.T) {
+	origMessages, origStore, origLimiter, origSecret := messages, messageStore, rateLimiter, rateLimitSecret
+	defer func() {
+		messages, messageStore, rateLimiter, rateLimitSecret = origMessages, origStore, origLimiter, origSecret
+	}()
+	messages = nil
+	rateLimitSecret = []byte("test-secret-not-for-production")
+
+	s, err := store.NewFileStore(filepath.Join(t.TempDir(), "guestbook.log"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	defer s.Close()
+	messageStore = s
+
+	rateLimiter = ratelimit.NewMemoryLimiter()
+	defer rateLimiter.Close()
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, NewHub())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	postSignedMessage(t, server.URL, "alice", "only entry", "proof-test-3")
+
+	resp, err := http.Get(server.URL + "/api/proof?index=99")
+	if err != nil {
+		t.Fatalf("GET /api/proof: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /api/proof?index=99: status %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
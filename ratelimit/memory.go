@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens     float64
+	lastRefill time.Time
+	lastSeen   time.Time
+}
+
+// shard is one independently-locked slice of the keyspace. Splitting into
+// shards lets concurrent requests for unrelated keys proceed without
+// contending on a single mutex, unlike the original process-global map.
+type shard struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// MemoryLimiter is a sharded, process-local Limiter. It runs a background
+// sweeper that evicts buckets idle longer than the configured TTL, so the
+// map can't grow unboundedly under a rotating-key attacker.
+type MemoryLimiter struct {
+	shards []*shard
+	ttl    time.Duration
+	stop   chan struct{}
+}
+
+const defaultShardCount = 32
+const defaultIdleTTL = 10 * time.Minute
+
+// NewMemoryLimiter creates a sharded in-memory Limiter and starts its
+// background sweeper. Call Close to stop the sweeper.
+func NewMemoryLimiter() *MemoryLimiter {
+	return newMemoryLimiter(defaultShardCount, defaultIdleTTL, time.Minute)
+}
+
+func newMemoryLimiter(shardCount int, idleTTL, sweepInterval time.Duration) *MemoryLimiter {
+	l := &MemoryLimiter{
+		shards: make([]*shard, shardCount),
+		ttl:    idleTTL,
+		stop:   make(chan struct{}),
+	}
+	for i := range l.shards {
+		l.shards[i] = &shard{buckets: make(map[string]*bucket)}
+	}
+	go l.sweepLoop(sweepInterval)
+	return l
+}
+
+func (l *MemoryLimiter) shardFor(key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return l.shards[h.Sum32()%uint32(len(l.shards))]
+}
+
+func (l *MemoryLimiter) Allow(key string) (bool, time.Duration, error) {
+	s := l.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: MaxTokens, lastRefill: now}
+		s.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.lastRefill = now
+	b.tokens = math.Min(MaxTokens, b.tokens+elapsed*RefillRate)
+
+	if b.tokens < 1 {
+		return false, retryAfter(b.tokens), nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+func (l *MemoryLimiter) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.sweep()
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+func (l *MemoryLimiter) sweep() {
+	cutoff := time.Now().Add(-l.ttl)
+	for _, s := range l.shards {
+		s.mu.Lock()
+		for key, b := range s.buckets {
+			if b.lastSeen.Before(cutoff) {
+				delete(s.buckets, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (l *MemoryLimiter) Close() error {
+	close(l.stop)
+	return nil
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterAllowsBurstThenDenies(t *testing.T) {
+	l := newMemoryLimiter(4, time.Minute, time.Hour)
+	defer l.Close()
+
+	for i := 0; i < MaxTokens; i++ {
+		allowed, _, err := l.Allow("device-a")
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		if !allowed {
+			t.Fatalf("Allow denied request %d of burst %d, want allowed", i+1, MaxTokens)
+		}
+	}
+
+	allowed, wait, err := l.Allow("device-a")
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if allowed {
+		t.Error("Allow permitted a request beyond the burst size, want denied")
+	}
+	if wait <= 0 {
+		t.Errorf("retryAfter = %v, want > 0", wait)
+	}
+}
+
+func TestMemoryLimiterKeysAreIndependent(t *testing.T) {
+	l := newMemoryLimiter(4, time.Minute, time.Hour)
+	defer l.Close()
+
+	for i := 0; i < MaxTokens; i++ {
+		if allowed, _, err := l.Allow("device-a"); err != nil || !allowed {
+			t.Fatalf("Allow(device-a) #%d: allowed=%v err=%v", i, allowed, err)
+		}
+	}
+
+	allowed, _, err := l.Allow("device-b")
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if !allowed {
+		t.Error("Allow(device-b) denied despite device-a's bucket being separately exhausted")
+	}
+}
+
+func TestMemoryLimiterRefillsOverTime(t *testing.T) {
+	l := newMemoryLimiter(4, time.Minute, time.Hour)
+	defer l.Close()
+
+	for i := 0; i < MaxTokens; i++ {
+		l.Allow("device-a")
+	}
+
+	s := l.shardFor("device-a")
+	s.mu.Lock()
+	s.buckets["device-a"].lastRefill = time.Now().Add(-time.Duration(MaxTokens/RefillRate) * time.Second)
+	s.mu.Unlock()
+
+	allowed, _, err := l.Allow("device-a")
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if !allowed {
+		t.Error("Allow denied after a full refill window elapsed, want allowed")
+	}
+}
+
+func TestMemoryLimiterSweepEvictsIdleBuckets(t *testing.T) {
+	l := newMemoryLimiter(4, time.Millisecond, time.Hour)
+	defer l.Close()
+
+	l.Allow("device-a")
+	s := l.shardFor("device-a")
+	if _, ok := s.buckets["device-a"]; !ok {
+		t.Fatal("bucket for device-a missing immediately after Allow")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	l.sweep()
+
+	s.mu.Lock()
+	_, ok := s.buckets["device-a"]
+	s.mu.Unlock()
+	if ok {
+		t.Error("sweep did not evict a bucket idle longer than ttl")
+	}
+}
@@ -0,0 +1,34 @@
+// Package ratelimit implements the token-bucket device rate limit used by
+// /api/post. It's pluggable so a single-instance deployment can use a cheap
+// in-process limiter while a multi-instance one shares state through Redis.
+package ratelimit
+
+import "time"
+
+const (
+	// MaxTokens is the burst size: a fresh key may post this many times
+	// before it has to wait on the refill rate.
+	MaxTokens = 8
+	// RefillRate is tokens regained per second (1 request every 4s sustained).
+	RefillRate = 0.25
+)
+
+// Limiter is a keyed token-bucket rate limiter. Each distinct key (e.g. a
+// device ID combined with a pubkey hash) gets its own independent bucket of
+// MaxTokens, refilling at RefillRate tokens/sec.
+type Limiter interface {
+	// Allow consumes one token for key if available. When it isn't,
+	// retryAfter estimates how long until the next token is available.
+	Allow(key string) (allowed bool, retryAfter time.Duration, err error)
+	Close() error
+}
+
+// retryAfter estimates the wait until a bucket holding tokens (< 1) regains
+// a whole token at RefillRate tokens/sec.
+func retryAfter(tokens float64) time.Duration {
+	deficit := 1 - tokens
+	if deficit <= 0 {
+		return 0
+	}
+	return time.Duration(deficit/RefillRate*1000) * time.Millisecond
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript atomically refills and consumes one token for KEYS[1].
+// It stores {tokens, last_refill_unix_ms} as a hash so concurrent requests
+// from different Fly instances see a consistent, single bucket per key.
+// Returns {allowed (0/1), tokens_remaining*1000}.
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local max_tokens = tonumber(ARGV[1])
+local refill_rate = tonumber(ARGV[2])
+local now_ms = tonumber(ARGV[3])
+local ttl_s = tonumber(ARGV[4])
+
+local data = redis.call("HMGET", key, "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+
+if tokens == nil then
+	tokens = max_tokens
+	ts = now_ms
+end
+
+local elapsed = math.max(0, now_ms - ts) / 1000
+tokens = math.min(max_tokens, tokens + elapsed * refill_rate)
+
+local allowed = 0
+if tokens >= 1 then
+	allowed = 1
+	tokens = tokens - 1
+end
+
+redis.call("HMSET", key, "tokens", tokens, "ts", now_ms)
+redis.call("EXPIRE", key, ttl_s)
+
+return {allowed, math.floor(tokens * 1000)}
+`)
+
+// RedisLimiter is a Limiter backed by Redis, so every Fly.io instance of the
+// service shares the same token buckets instead of each tracking its own.
+type RedisLimiter struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisLimiter connects to addr (e.g. "redis.internal:6379") and returns
+// a Limiter. keyPrefix namespaces keys (e.g. "pq-guestbook:ratelimit:").
+func NewRedisLimiter(addr, password string, db int, keyPrefix string) (*RedisLimiter, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ratelimit: connect to redis at %s: %w", addr, err)
+	}
+
+	return &RedisLimiter{client: client, prefix: keyPrefix}, nil
+}
+
+func (l *RedisLimiter) Allow(key string) (bool, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// TTL of idle buckets: long enough to outlast any legitimate burst gap,
+	// short enough that abandoned keys don't linger forever.
+	const idleTTLSeconds = 600
+
+	res, err := tokenBucketScript.Run(ctx, l.client, []string{l.prefix + key},
+		MaxTokens, RefillRate, time.Now().UnixMilli(), idleTTLSeconds).Result()
+	if err != nil {
+		return false, 0, fmt.Errorf("ratelimit: redis script: %w", err)
+	}
+
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != 2 {
+		return false, 0, fmt.Errorf("ratelimit: unexpected script result %v", res)
+	}
+	allowed, _ := vals[0].(int64)
+	tokensMilli, _ := vals[1].(int64)
+
+	if allowed == 1 {
+		return true, 0, nil
+	}
+	return false, retryAfter(float64(tokensMilli) / 1000), nil
+}
+
+func (l *RedisLimiter) Close() error {
+	return l.client.Close()
+}
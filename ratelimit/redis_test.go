@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedisLimiter points a RedisLimiter at a miniredis instance instead
+// of a real Redis server, so these tests exercise the actual Lua script
+// (tokenBucketScript) and its return-value type assertions rather than
+// reimplementing the bucket math in Go.
+func newTestRedisLimiter(t *testing.T) (*RedisLimiter, *miniredis.Miniredis) {
+	t.Helper()
+
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run: %v", err)
+	}
+	t.Cleanup(s.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return &RedisLimiter{client: client, prefix: "test:"}, s
+}
+
+func TestRedisLimiterAllowsBurstThenDenies(t *testing.T) {
+	l, _ := newTestRedisLimiter(t)
+
+	for i := 0; i < MaxTokens; i++ {
+		allowed, _, err := l.Allow("device-a")
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		if !allowed {
+			t.Fatalf("Allow denied request %d of burst %d, want allowed", i+1, MaxTokens)
+		}
+	}
+
+	allowed, wait, err := l.Allow("device-a")
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if allowed {
+		t.Error("Allow permitted a request beyond the burst size, want denied")
+	}
+	if wait <= 0 {
+		t.Errorf("retryAfter = %v, want > 0", wait)
+	}
+}
+
+func TestRedisLimiterKeysAreIndependent(t *testing.T) {
+	l, _ := newTestRedisLimiter(t)
+
+	for i := 0; i < MaxTokens; i++ {
+		if allowed, _, err := l.Allow("device-a"); err != nil || !allowed {
+			t.Fatalf("Allow(device-a) #%d: allowed=%v err=%v", i, allowed, err)
+		}
+	}
+
+	allowed, _, err := l.Allow("device-b")
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if !allowed {
+		t.Error("Allow(device-b) denied despite device-a's bucket being separately exhausted")
+	}
+}
+
+func TestRedisLimiterRefillsOverTime(t *testing.T) {
+	l, s := newTestRedisLimiter(t)
+
+	for i := 0; i < MaxTokens; i++ {
+		if _, _, err := l.Allow("device-a"); err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+	}
+
+	if allowed, _, err := l.Allow("device-a"); err != nil {
+		t.Fatalf("Allow: %v", err)
+	} else if allowed {
+		t.Fatal("Allow allowed a request right after the burst was exhausted, want denied")
+	}
+
+	// now_ms comes from Go's clock, not miniredis's own (which FastForward
+	// advances), so back-date the bucket's stored "ts" field directly -
+	// same idea as memory_test.go rewinding lastRefill on the bucket.
+	key := "test:device-a"
+	elapsed := time.Duration(MaxTokens/RefillRate*float64(time.Second)) + time.Second
+	pastMs := time.Now().Add(-elapsed).UnixMilli()
+	s.HSet(key, "ts", fmt.Sprintf("%d", pastMs))
+
+	allowed, _, err := l.Allow("device-a")
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if !allowed {
+		t.Error("Allow denied after a full refill window elapsed, want allowed")
+	}
+}
+
+func TestRedisLimiterUnexpectedScriptResultIsAnError(t *testing.T) {
+	l, _ := newTestRedisLimiter(t)
+
+	if _, _, err := l.Allow("device-a"); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+
+	if err := l.client.Close(); err != nil {
+		t.Fatalf("client.Close: %v", err)
+	}
+
+	if _, _, err := l.Allow("device-a"); err == nil {
+		t.Error("Allow on a closed client succeeded, want an error")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resetReplayState clears the package-level replay-cache globals and
+// restores them after the test, so tests don't leak state into each other.
+func resetReplayState(t *testing.T) {
+	t.Helper()
+	origSeen, origRejected := seen, atomic.LoadInt64(&replayRejectedTotal)
+	seen = make(map[string]map[int64]bool)
+	atomic.StoreInt64(&replayRejectedTotal, 0)
+	t.Cleanup(func() {
+		seen = origSeen
+		atomic.StoreInt64(&replayRejectedTotal, origRejected)
+	})
+}
+
+func TestReplaySeenRejectsRepeatAndOverflow(t *testing.T) {
+	resetReplayState(t)
+
+	pubkey := []byte("pubkey-a")
+	if replaySeen(pubkey, 1) {
+		t.Fatal("first sighting of (pubkey, ts) reported as a replay")
+	}
+	if !replaySeen(pubkey, 1) {
+		t.Error("repeat (pubkey, ts) not detected as a replay")
+	}
+
+	for ts := int64(2); ts <= maxSeenPerPubkey; ts++ {
+		if replaySeen(pubkey, ts) {
+			t.Fatalf("ts %d within cap reported as a replay", ts)
+		}
+	}
+	if !replaySeen(pubkey, maxSeenPerPubkey+1) {
+		t.Error("timestamp beyond maxSeenPerPubkey not rejected")
+	}
+
+	if got := atomic.LoadInt64(&replayRejectedTotal); got != 2 {
+		t.Errorf("replayRejectedTotal = %d, want 2", got)
+	}
+}
+
+func TestReplayCacheEntriesCountsAcrossPubkeys(t *testing.T) {
+	resetReplayState(t)
+
+	replaySeen([]byte("a"), 1)
+	replaySeen([]byte("a"), 2)
+	replaySeen([]byte("b"), 1)
+
+	if got := replayCacheEntries(); got != 3 {
+		t.Errorf("replayCacheEntries() = %d, want 3", got)
+	}
+}
+
+func TestSweepReplayCacheRemovesStaleEntries(t *testing.T) {
+	resetReplayState(t)
+
+	now := time.Now().UnixMilli()
+	replaySeen([]byte("stale"), now-2*freshnessWindowMs)
+	replaySeen([]byte("fresh"), now)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		sweepReplayCache(5*time.Millisecond, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done // wait out the goroutine before resetReplayState's cleanup touches seen
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && replayCacheEntries() != 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := replayCacheEntries(); got != 1 {
+		t.Fatalf("replayCacheEntries() after sweep = %d, want 1 (only the fresh entry)", got)
+	}
+}
+
+func TestMetricsHandlerReportsReplayCounters(t *testing.T) {
+	resetReplayState(t)
+	replaySeen([]byte("a"), 1)
+	replaySeen([]byte("a"), 1) // rejected as a repeat
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, NewHub())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+
+	text := string(body)
+	if !strings.Contains(text, "replay_rejected_total 1\n") {
+		t.Errorf("/metrics body missing replay_rejected_total 1:\n%s", text)
+	}
+	if !strings.Contains(text, "replay_cache_entries 1\n") {
+		t.Errorf("/metrics body missing replay_cache_entries 1:\n%s", text)
+	}
+}
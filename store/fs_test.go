@@ -0,0 +1,144 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestNewFileStoreRecoversFromTornTrailingWrite reproduces a crash, OOM kill,
+// or power loss right after Append's Write/Sync: the last line in the log is
+// an incomplete JSON fragment. NewFileStore must drop just that line and
+// keep serving everything before it, rather than refusing to open at all.
+func TestNewFileStoreRecoversFromTornTrailingWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guestbook.log")
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.Append("author", "content", int64(i), []byte("payload"), "mldsa65", "sig", "pubkey"); err != nil {
+			t.Fatalf("Append %d: %v", i, err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	// Simulate a torn write: chop the last 5 bytes off the file, leaving the
+	// final entry's JSON line incomplete.
+	if len(data) < 5 {
+		t.Fatalf("log file too short to truncate: %d bytes", len(data))
+	}
+	if err := os.WriteFile(path, data[:len(data)-5], 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore on a torn trailing write returned an error, want recovery: %v", err)
+	}
+	defer reopened.Close()
+
+	entries, err := reopened.Replay()
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Replay returned %d entries, want 1 (the torn second entry should be dropped)", len(entries))
+	}
+	if entries[0].Index != 0 {
+		t.Errorf("surviving entry has Index %d, want 0", entries[0].Index)
+	}
+
+	// The store must still be appendable after recovery, continuing the
+	// chain from the surviving entry rather than the dropped one.
+	appended, err := reopened.Append("author", "content", 2, []byte("payload"), "mldsa65", "sig", "pubkey")
+	if err != nil {
+		t.Fatalf("Append after recovery: %v", err)
+	}
+	if appended.Index != 1 {
+		t.Errorf("Append after recovery got Index %d, want 1", appended.Index)
+	}
+}
+
+// TestNewFileStoreRejectsNonTrailingCorruption confirms that a corrupt line
+// anywhere but the end of the file is still fatal: that's not the
+// interrupted-append case, but evidence of real tampering or corruption.
+func TestNewFileStoreRejectsNonTrailingCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guestbook.log")
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.Append("author", "content", int64(i), []byte("payload"), "mldsa65", "sig", "pubkey"); err != nil {
+			t.Fatalf("Append %d: %v", i, err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	// Corrupt the first line while leaving the rest - including the last
+	// line - intact.
+	data[0] = '!'
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := NewFileStore(path); err == nil {
+		t.Error("NewFileStore with a corrupt non-trailing line returned nil error, want a fatal error")
+	}
+}
+
+// TestNewFileStoreRejectsCompleteButMalformedTrailingLine confirms that a
+// last line which is the right length but holds tampered content - not cut
+// short mid-write - is still fatal, rather than being mistaken for a torn
+// write and silently discarded.
+func TestNewFileStoreRejectsCompleteButMalformedTrailingLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guestbook.log")
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.Append("author", "content", int64(i), []byte("payload"), "mldsa65", "sig", "pubkey"); err != nil {
+			t.Fatalf("Append %d: %v", i, err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	// Corrupt a byte inside the last line without changing the file's
+	// length, so this can't be mistaken for a truncated write.
+	nl := bytes.LastIndexByte(data[:len(data)-1], '\n')
+	if nl < 0 {
+		t.Fatalf("expected at least two lines in %q", data)
+	}
+	data[nl+2] = '!'
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := NewFileStore(path); err == nil {
+		t.Error("NewFileStore with a complete-but-malformed trailing line returned nil error, want a fatal error")
+	}
+}
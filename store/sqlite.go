@@ -0,0 +1,152 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	_ "modernc.org/sqlite" // pure-Go driver, registers as "sqlite"
+)
+
+// SQLiteStore is a Store backed by a SQLite database, suited to deployments
+// that want transactional durability without an external dependency.
+type SQLiteStore struct {
+	mu sync.Mutex
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the database at path and ensures the
+// entries table exists.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("store: open %s: %w", path, err)
+	}
+
+	const schema = `
+CREATE TABLE IF NOT EXISTS entries (
+	idx        INTEGER PRIMARY KEY,
+	prev_hash  TEXT NOT NULL,
+	entry_hash TEXT NOT NULL,
+	payload    BLOB NOT NULL,
+	algo       TEXT NOT NULL,
+	sig        TEXT NOT NULL,
+	pubkey     TEXT NOT NULL,
+	author     TEXT NOT NULL,
+	content    TEXT NOT NULL,
+	timestamp  INTEGER NOT NULL
+);`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: migrate: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Append(author, content string, ts int64, payload []byte, algo, sig, pubkey string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer tx.Rollback()
+
+	var prev string
+	var next uint64
+	row := tx.QueryRow(`SELECT entry_hash, idx FROM entries ORDER BY idx DESC LIMIT 1`)
+	switch err := row.Scan(&prev, &next); err {
+	case nil:
+		next++
+	case sql.ErrNoRows:
+		prev = GenesisHash
+		next = 0
+	default:
+		return Entry{}, fmt.Errorf("store: read head: %w", err)
+	}
+
+	e := Entry{
+		Index:     next,
+		PrevHash:  prev,
+		Payload:   payload,
+		Algo:      algo,
+		Sig:       sig,
+		PubKey:    pubkey,
+		Author:    author,
+		Content:   content,
+		Timestamp: ts,
+	}
+	e.EntryHash = EntryHash(e.PrevHash, e.Payload, e.Sig, e.PubKey)
+
+	_, err = tx.Exec(`INSERT INTO entries (idx, prev_hash, entry_hash, payload, algo, sig, pubkey, author, content, timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Index, e.PrevHash, e.EntryHash, e.Payload, e.Algo, e.Sig, e.PubKey, e.Author, e.Content, e.Timestamp)
+	if err != nil {
+		return Entry{}, fmt.Errorf("store: insert: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+func (s *SQLiteStore) Replay() ([]Entry, error) {
+	return s.queryFrom(0)
+}
+
+func (s *SQLiteStore) Proof(index uint64) ([]Entry, error) {
+	entries, err := s.queryFrom(index)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("store: index %d out of range", index)
+	}
+	return entries, nil
+}
+
+func (s *SQLiteStore) queryFrom(index uint64) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows, err := s.db.Query(`SELECT idx, prev_hash, entry_hash, payload, algo, sig, pubkey, author, content, timestamp FROM entries WHERE idx >= ? ORDER BY idx ASC`, index)
+	if err != nil {
+		return nil, fmt.Errorf("store: query: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Index, &e.PrevHash, &e.EntryHash, &e.Payload, &e.Algo, &e.Sig, &e.PubKey, &e.Author, &e.Content, &e.Timestamp); err != nil {
+			return nil, fmt.Errorf("store: scan: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) Head() (string, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var hash string
+	var idx uint64
+	row := s.db.QueryRow(`SELECT entry_hash, idx FROM entries ORDER BY idx DESC LIMIT 1`)
+	switch err := row.Scan(&hash, &idx); err {
+	case nil:
+		return hash, idx + 1, nil
+	case sql.ErrNoRows:
+		return GenesisHash, 0, nil
+	default:
+		return "", 0, fmt.Errorf("store: head: %w", err)
+	}
+}
+
+func (s *SQLiteStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Close()
+}
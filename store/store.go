@@ -0,0 +1,91 @@
+// Package store implements a tamper-evident, append-only log for guestbook
+// entries. Every accepted message is chained to the one before it via
+// entry_hash = SHA256(prev_hash || payload || sig || pubkey), so any edit or
+// reordering of the underlying storage breaks the chain and is detectable on
+// replay.
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GenesisHash is the prev_hash of the first entry in the chain: 32 zero
+// bytes, hex-encoded to the same width as a SHA-256 sum.
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// ErrChainBroken is returned (wrapped) when a replayed log's hashes don't
+// link up, indicating the underlying storage was tampered with.
+var ErrChainBroken = errors.New("store: hash chain broken")
+
+// Entry is one link in the hash chain. Payload is the exact canonical bytes
+// that were signed, so a verifier can re-check Sig/PubKey independently of
+// the chain linkage. Author, Content and Timestamp are stored alongside it,
+// rather than derived by re-splitting Payload, since the canonical encoding
+// ("author\ncontent\nts") isn't unambiguously reversible when Content itself
+// contains a newline.
+type Entry struct {
+	Index     uint64 `json:"index"`
+	PrevHash  string `json:"prev_hash"`
+	EntryHash string `json:"entry_hash"`
+	Payload   []byte `json:"payload"`
+	Algo      string `json:"algo"`
+	Sig       string `json:"sig"`
+	PubKey    string `json:"pubkey"`
+	Author    string `json:"author"`
+	Content   string `json:"content"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Store is a hash-chained append-only log of guestbook entries.
+type Store interface {
+	// Append adds a new entry to the end of the chain and returns it with
+	// Index, PrevHash and EntryHash populated. Author, content and ts are
+	// recorded verbatim alongside payload (the canonical signed bytes) so
+	// callers can reconstruct the entry without re-parsing payload.
+	Append(author, content string, ts int64, payload []byte, algo, sig, pubkey string) (Entry, error)
+	// Replay returns every entry in the log in index order.
+	Replay() ([]Entry, error)
+	// Head returns the hash of the last entry and the current chain length.
+	// With an empty store it returns (GenesisHash, 0, nil).
+	Head() (hash string, length uint64, err error)
+	// Proof returns the entries from index (inclusive) through the head, so
+	// a caller can independently recompute the chain and confirm it leads to
+	// the current head hash - an audit path in the spirit of a Merkle log.
+	Proof(index uint64) ([]Entry, error)
+	Close() error
+}
+
+// EntryHash computes entry_hash = SHA256(prev_hash || payload || sig || pubkey).
+func EntryHash(prevHash string, payload []byte, sig, pubkey string) string {
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write(payload)
+	h.Write([]byte(sig))
+	h.Write([]byte(pubkey))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyChain recomputes every hash in entries (which must be in index
+// order starting from index 0) and confirms each links to the next. It
+// returns the head hash on success.
+func VerifyChain(entries []Entry) (head string, err error) {
+	prev := GenesisHash
+	for i, e := range entries {
+		if e.Index != uint64(i) {
+			return "", fmt.Errorf("%w: entry %d has index %d", ErrChainBroken, i, e.Index)
+		}
+		if e.PrevHash != prev {
+			return "", fmt.Errorf("%w: entry %d prev_hash mismatch", ErrChainBroken, i)
+		}
+		want := EntryHash(e.PrevHash, e.Payload, e.Sig, e.PubKey)
+		if want != e.EntryHash {
+			return "", fmt.Errorf("%w: entry %d hash mismatch", ErrChainBroken, i)
+		}
+		prev = e.EntryHash
+	}
+	return prev, nil
+}
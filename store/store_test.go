@@ -0,0 +1,216 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// storeFactories lets the shared test bodies below run against every Store
+// implementation.
+func storeFactories(t *testing.T) map[string]func() Store {
+	t.Helper()
+	return map[string]func() Store{
+		"FileStore": func() Store {
+			s, err := NewFileStore(filepath.Join(t.TempDir(), "guestbook.log"))
+			if err != nil {
+				t.Fatalf("NewFileStore: %v", err)
+			}
+			return s
+		},
+		"SQLiteStore": func() Store {
+			s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "guestbook.db"))
+			if err != nil {
+				t.Fatalf("NewSQLiteStore: %v", err)
+			}
+			return s
+		},
+	}
+}
+
+func TestAppendRoundTripsMultilineContent(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore()
+			defer s.Close()
+
+			const author, content = "alice", "hello\nworld\nmultiple lines"
+			const ts = int64(1700000000000)
+			payload := []byte(author + "\n" + content + "\n" + "1700000000000")
+
+			got, err := s.Append(author, content, ts, payload, "mldsa65", "sig", "pubkey")
+			if err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+			if got.Author != author || got.Content != content || got.Timestamp != ts {
+				t.Fatalf("Append returned {%q %q %d}, want {%q %q %d}",
+					got.Author, got.Content, got.Timestamp, author, content, ts)
+			}
+
+			entries, err := s.Replay()
+			if err != nil {
+				t.Fatalf("Replay: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("Replay returned %d entries, want 1", len(entries))
+			}
+			if entries[0].Content != content {
+				t.Errorf("replayed Content = %q, want %q", entries[0].Content, content)
+			}
+		})
+	}
+}
+
+func TestAppendChainsAndVerifies(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore()
+			defer s.Close()
+
+			for i := 0; i < 5; i++ {
+				payload := []byte("payload")
+				if _, err := s.Append("author", "content", int64(i), payload, "mldsa65", "sig", "pubkey"); err != nil {
+					t.Fatalf("Append %d: %v", i, err)
+				}
+			}
+
+			entries, err := s.Replay()
+			if err != nil {
+				t.Fatalf("Replay: %v", err)
+			}
+			head, err := VerifyChain(entries)
+			if err != nil {
+				t.Fatalf("VerifyChain: %v", err)
+			}
+
+			wantHead, length, err := s.Head()
+			if err != nil {
+				t.Fatalf("Head: %v", err)
+			}
+			if length != uint64(len(entries)) {
+				t.Errorf("Head length = %d, want %d", length, len(entries))
+			}
+			if head != wantHead {
+				t.Errorf("VerifyChain head = %q, want %q", head, wantHead)
+			}
+		})
+	}
+}
+
+func TestVerifyChainDetectsTampering(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore()
+			defer s.Close()
+
+			for i := 0; i < 3; i++ {
+				if _, err := s.Append("author", "content", int64(i), []byte("payload"), "mldsa65", "sig", "pubkey"); err != nil {
+					t.Fatalf("Append %d: %v", i, err)
+				}
+			}
+
+			entries, err := s.Replay()
+			if err != nil {
+				t.Fatalf("Replay: %v", err)
+			}
+			entries[1].Payload = []byte("tampered payload")
+
+			if _, err := VerifyChain(entries); err == nil {
+				t.Error("VerifyChain returned nil error for a tampered entry, want ErrChainBroken")
+			}
+		})
+	}
+}
+
+func TestHeadOnEmptyStoreIsGenesis(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore()
+			defer s.Close()
+
+			hash, length, err := s.Head()
+			if err != nil {
+				t.Fatalf("Head: %v", err)
+			}
+			if hash != GenesisHash || length != 0 {
+				t.Errorf("Head on empty store = (%q, %d), want (%q, 0)", hash, length, GenesisHash)
+			}
+		})
+	}
+}
+
+func TestProofReturnsSuffixFromIndex(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore()
+			defer s.Close()
+
+			for i := 0; i < 5; i++ {
+				if _, err := s.Append("author", "content", int64(i), []byte("payload"), "mldsa65", "sig", "pubkey"); err != nil {
+					t.Fatalf("Append %d: %v", i, err)
+				}
+			}
+
+			wantHead, _, err := s.Head()
+			if err != nil {
+				t.Fatalf("Head: %v", err)
+			}
+
+			proof, err := s.Proof(2)
+			if err != nil {
+				t.Fatalf("Proof(2): %v", err)
+			}
+			if len(proof) != 3 {
+				t.Fatalf("Proof(2) returned %d entries, want 3", len(proof))
+			}
+			for i, e := range proof {
+				if e.Index != uint64(2+i) {
+					t.Errorf("proof[%d].Index = %d, want %d", i, e.Index, 2+i)
+				}
+			}
+			if proof[len(proof)-1].EntryHash != wantHead {
+				t.Errorf("proof's last EntryHash = %q, want head %q", proof[len(proof)-1].EntryHash, wantHead)
+			}
+
+			// Proof(0) is the whole chain, so it must re-verify the same way
+			// Replay does.
+			full, err := s.Proof(0)
+			if err != nil {
+				t.Fatalf("Proof(0): %v", err)
+			}
+			head, err := VerifyChain(full)
+			if err != nil {
+				t.Fatalf("VerifyChain(Proof(0)): %v", err)
+			}
+			if head != wantHead {
+				t.Errorf("VerifyChain(Proof(0)) head = %q, want %q", head, wantHead)
+			}
+		})
+	}
+}
+
+func TestProofOutOfRangeIsAnError(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore()
+			defer s.Close()
+
+			if _, err := s.Append("author", "content", 0, []byte("payload"), "mldsa65", "sig", "pubkey"); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+
+			if _, err := s.Proof(1); err == nil {
+				t.Error("Proof(1) on a 1-entry store returned nil error, want out-of-range error")
+			}
+			if _, err := s.Proof(100); err == nil {
+				t.Error("Proof(100) on a 1-entry store returned nil error, want out-of-range error")
+			}
+		})
+	}
+}
+
+func TestGenesisHashIsOneSHA256Width(t *testing.T) {
+	const wantLen = 64 // hex-encoded sha256.Size
+	if len(GenesisHash) != wantLen {
+		t.Errorf("len(GenesisHash) = %d, want %d", len(GenesisHash), wantLen)
+	}
+}
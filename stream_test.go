@@ -0,0 +1,565 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	mldsa65 "github.com/cloudflare/circl/sign/mldsa/mldsa65"
+
+	"github.com/Marqui-13/pq-guestbook/ratelimit"
+	"github.com/Marqui-13/pq-guestbook/store"
+)
+
+// TestStreamReceivesPostedMessage posts a validly-signed message over HTTP
+// and asserts a concurrently-connected /api/stream client receives it
+// within a deadline.
+func TestStreamReceivesPostedMessage(t *testing.T) {
+	origMessages, origStore, origLimiter, origSecret := messages, messageStore, rateLimiter, rateLimitSecret
+	defer func() {
+		messages, messageStore, rateLimiter, rateLimitSecret = origMessages, origStore, origLimiter, origSecret
+	}()
+	messages = nil
+	rateLimitSecret = []byte("test-secret-not-for-production")
+
+	s, err := store.NewFileStore(filepath.Join(t.TempDir(), "guestbook.log"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	defer s.Close()
+	messageStore = s
+
+	rateLimiter = ratelimit.NewMemoryLimiter()
+	defer rateLimiter.Close()
+
+	hub := NewHub()
+	hubStop := make(chan struct{})
+	go hub.Run(hubStop)
+	defer close(hubStop)
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, hub)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/stream"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial /api/stream: %v", err)
+	}
+	defer conn.Close()
+
+	pub, priv, err := mldsa65.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("mldsa65 keygen: %v", err)
+	}
+	pubBytes, err := pub.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal pubkey: %v", err)
+	}
+
+	const author, content = "streamer", "hello over the wire"
+	ts := time.Now().UnixMilli()
+	canonical := canonicalPayload(author, content, ts)
+	sig, err := priv.Sign(rand.Reader, canonical, crypto.Hash(0))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	body, err := json.Marshal(Message{
+		Author:    author,
+		Content:   content,
+		Timestamp: ts,
+		Algo:      "mldsa65",
+		PubKey:    base64.RawStdEncoding.EncodeToString(pubBytes),
+		Signature: base64.RawStdEncoding.EncodeToString(sig),
+		UserAgent: "stream-test-agent",
+	})
+	if err != nil {
+		t.Fatalf("marshal message: %v", err)
+	}
+
+	resp, err := http.Post(server.URL+"/api/post", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /api/post: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /api/post: status %d", resp.StatusCode)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var received Message
+	if err := conn.ReadJSON(&received); err != nil {
+		t.Fatalf("reading from stream: %v", err)
+	}
+
+	if received.Author != author || received.Content != content {
+		t.Errorf("received = %+v, want author=%q content=%q", received, author, content)
+	}
+}
+
+// TestStreamReplaysLargeBacklogWithoutDeadlock posts more messages than
+// wsSendBuffer before connecting with ?since=1, which used to hang the
+// handler goroutine forever: the backlog was written into client.send
+// before writePump started draining it.
+func TestStreamReplaysLargeBacklogWithoutDeadlock(t *testing.T) {
+	origMessages, origStore, origLimiter, origSecret := messages, messageStore, rateLimiter, rateLimitSecret
+	defer func() {
+		messages, messageStore, rateLimiter, rateLimitSecret = origMessages, origStore, origLimiter, origSecret
+	}()
+	messages = nil
+	rateLimitSecret = []byte("test-secret-not-for-production")
+
+	s, err := store.NewFileStore(filepath.Join(t.TempDir(), "guestbook.log"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	defer s.Close()
+	messageStore = s
+
+	rateLimiter = ratelimit.NewMemoryLimiter()
+	defer rateLimiter.Close()
+
+	hub := NewHub()
+	hubStop := make(chan struct{})
+	go hub.Run(hubStop)
+	defer close(hubStop)
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, hub)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	pub, priv, err := mldsa65.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("mldsa65 keygen: %v", err)
+	}
+	pubBytes, err := pub.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal pubkey: %v", err)
+	}
+	pubB64 := base64.RawStdEncoding.EncodeToString(pubBytes)
+
+	const backlogSize = wsSendBuffer + 10
+	for i := 0; i < backlogSize; i++ {
+		ts := time.Now().UnixMilli()
+		content := "backlog message"
+		canonical := canonicalPayload("backlogger", content, ts)
+		sig, err := priv.Sign(rand.Reader, canonical, crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+
+		body, err := json.Marshal(Message{
+			Author:    "backlogger",
+			Content:   content,
+			Timestamp: ts,
+			Algo:      "mldsa65",
+			PubKey:    pubB64,
+			Signature: base64.RawStdEncoding.EncodeToString(sig),
+			// Each message uses a distinct User-Agent so the per-device
+			// rate limiter (burst of ratelimit.MaxTokens) doesn't reject
+			// posts before the backlog is large enough to exercise the bug.
+			UserAgent: fmt.Sprintf("stream-test-agent-%d", i),
+		})
+		if err != nil {
+			t.Fatalf("marshal message: %v", err)
+		}
+
+		resp, err := http.Post(server.URL+"/api/post", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST /api/post: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /api/post: status %d", resp.StatusCode)
+		}
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/stream?since=1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial /api/stream: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < backlogSize; i++ {
+		var received Message
+		if err := conn.ReadJSON(&received); err != nil {
+			t.Fatalf("reading backlog message %d/%d: %v", i+1, backlogSize, err)
+		}
+	}
+}
+
+// TestStreamBacklogDeliveredBeforeConcurrentLivePost covers a client
+// connecting with ?since=<ts> against a backlog bigger than wsSendBuffer
+// while a brand-new message is posted concurrently. Because serveStream
+// used to register with the hub before the backlog was fully queued, the
+// live post could slip into client.send ahead of older backlog entries
+// still waiting their turn, delivering history out of order even though
+// replayedThrough already kept it from being delivered twice.
+func TestStreamBacklogDeliveredBeforeConcurrentLivePost(t *testing.T) {
+	origMessages, origStore, origLimiter, origSecret := messages, messageStore, rateLimiter, rateLimitSecret
+	defer func() {
+		messages, messageStore, rateLimiter, rateLimitSecret = origMessages, origStore, origLimiter, origSecret
+	}()
+	messages = nil
+	rateLimitSecret = []byte("test-secret-not-for-production")
+
+	s, err := store.NewFileStore(filepath.Join(t.TempDir(), "guestbook.log"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	defer s.Close()
+	messageStore = s
+
+	rateLimiter = ratelimit.NewMemoryLimiter()
+	defer rateLimiter.Close()
+
+	hub := NewHub()
+	hubStop := make(chan struct{})
+	go hub.Run(hubStop)
+	defer close(hubStop)
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, hub)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	pub, priv, err := mldsa65.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("mldsa65 keygen: %v", err)
+	}
+	pubBytes, err := pub.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal pubkey: %v", err)
+	}
+	pubB64 := base64.RawStdEncoding.EncodeToString(pubBytes)
+
+	sign := func(content string, ts int64) []byte {
+		canonical := canonicalPayload("backlogger", content, ts)
+		sig, err := priv.Sign(rand.Reader, canonical, crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return sig
+	}
+	post := func(content, ua string) {
+		ts := time.Now().UnixMilli()
+		body, err := json.Marshal(Message{
+			Author:    "backlogger",
+			Content:   content,
+			Timestamp: ts,
+			Algo:      "mldsa65",
+			PubKey:    pubB64,
+			Signature: base64.RawStdEncoding.EncodeToString(sign(content, ts)),
+			UserAgent: ua,
+		})
+		if err != nil {
+			t.Fatalf("marshal message: %v", err)
+		}
+		resp, err := http.Post(server.URL+"/api/post", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST /api/post: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /api/post: status %d", resp.StatusCode)
+		}
+	}
+
+	const backlogSize = wsSendBuffer + 10
+	for i := 0; i < backlogSize; i++ {
+		post("backlog message", fmt.Sprintf("ordering-test-seed-%d", i))
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/stream?since=1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial /api/stream: %v", err)
+	}
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		post("live message", "ordering-test-live")
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var received []Message
+	for {
+		var m Message
+		if err := conn.ReadJSON(&m); err != nil {
+			break
+		}
+		received = append(received, m)
+		if len(received) == backlogSize+1 {
+			break
+		}
+	}
+	wg.Wait()
+
+	if len(received) != backlogSize+1 {
+		t.Fatalf("received %d messages, want %d (backlog + the live post)", len(received), backlogSize+1)
+	}
+	for i, m := range received[:backlogSize] {
+		if m.Content != "backlog message" {
+			t.Fatalf("message %d = %q, want backlog message delivered before the live post", i, m.Content)
+		}
+	}
+	if got := received[backlogSize].Content; got != "live message" {
+		t.Fatalf("last message = %q, want the live post delivered after the full backlog", got)
+	}
+}
+
+// TestStreamDisconnectDuringBacklogReplayDoesNotPanic dials /api/stream with
+// a backlog larger than wsSendBuffer and closes the connection immediately,
+// without reading anything, while posts keep landing concurrently. Before
+// serveStream's backlog loop learned to give up on a disconnected client
+// instead of assuming client.send stays open for its whole run, this
+// reliably panicked with "send on closed channel" within a few dozen
+// iterations.
+func TestStreamDisconnectDuringBacklogReplayDoesNotPanic(t *testing.T) {
+	origMessages, origStore, origLimiter, origSecret := messages, messageStore, rateLimiter, rateLimitSecret
+	defer func() {
+		messages, messageStore, rateLimiter, rateLimitSecret = origMessages, origStore, origLimiter, origSecret
+	}()
+	messages = nil
+	rateLimitSecret = []byte("test-secret-not-for-production")
+
+	s, err := store.NewFileStore(filepath.Join(t.TempDir(), "guestbook.log"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	defer s.Close()
+	messageStore = s
+
+	rateLimiter = ratelimit.NewMemoryLimiter()
+	defer rateLimiter.Close()
+
+	hub := NewHub()
+	hubStop := make(chan struct{})
+	go hub.Run(hubStop)
+	defer close(hubStop)
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, hub)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	pub, priv, err := mldsa65.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("mldsa65 keygen: %v", err)
+	}
+	pubBytes, err := pub.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal pubkey: %v", err)
+	}
+	pubB64 := base64.RawStdEncoding.EncodeToString(pubBytes)
+
+	// Seed a backlog bigger than wsSendBuffer so replay can't finish inside
+	// a single buffer-sized burst.
+	const backlogSize = wsSendBuffer + 10
+	for i := 0; i < backlogSize; i++ {
+		ts := time.Now().UnixMilli()
+		content := "backlog message"
+		canonical := canonicalPayload("backlogger", content, ts)
+		sig, err := priv.Sign(rand.Reader, canonical, crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		body, err := json.Marshal(Message{
+			Author:    "backlogger",
+			Content:   content,
+			Timestamp: ts,
+			Algo:      "mldsa65",
+			PubKey:    pubB64,
+			Signature: base64.RawStdEncoding.EncodeToString(sig),
+			UserAgent: fmt.Sprintf("disconnect-test-seed-%d", i),
+		})
+		if err != nil {
+			t.Fatalf("marshal message: %v", err)
+		}
+		resp, err := http.Post(server.URL+"/api/post", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST /api/post: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/stream?since=1"
+
+	const rounds = 40
+	for i := 0; i < rounds; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("dial /api/stream: %v", err)
+		}
+		// Close immediately, without reading any of the backlog: readPump
+		// should fire hub.unregister before the backlog loop has drained,
+		// which is exactly the race the fix covers.
+		conn.Close()
+
+		ts := time.Now().UnixMilli()
+		content := "concurrent post"
+		canonical := canonicalPayload("backlogger", content, ts)
+		sig, err := priv.Sign(rand.Reader, canonical, crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		body, err := json.Marshal(Message{
+			Author:    "backlogger",
+			Content:   content,
+			Timestamp: ts,
+			Algo:      "mldsa65",
+			PubKey:    pubB64,
+			Signature: base64.RawStdEncoding.EncodeToString(sig),
+			UserAgent: fmt.Sprintf("disconnect-test-live-%d", i),
+		})
+		if err != nil {
+			t.Fatalf("marshal message: %v", err)
+		}
+		resp, err := http.Post(server.URL+"/api/post", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST /api/post: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// If serveStream's backlog loop panicked on a closed channel, the
+	// net/http server would have recovered it per-request but this test
+	// process would still be left with a dead hub goroutine; reaching here
+	// without a panic (caught by `go test` itself) is the assertion.
+}
+
+// TestStreamLiveMessageNotLostWhileBacklogReplayIsInFlight covers the
+// window where serveStream used to register a client with the hub only
+// after its entire backlog had been pushed into client.send. A message
+// posted after the backlog snapshot was taken but before that registration
+// completed was appended to messages and broadcast while the client was in
+// neither the snapshot (already taken) nor hub.clients (not yet
+// registered), so Hub.Run's broadcast case never found it and silently
+// dropped it for good. This test seeds a backlog bigger than wsSendBuffer,
+// connects without reading anything, posts one more message while the
+// handler's sendBlocking loop is necessarily still stalled on the full
+// send buffer, then starts draining and asserts that message still shows
+// up - it must not vanish just because registration was slow.
+func TestStreamLiveMessageNotLostWhileBacklogReplayIsInFlight(t *testing.T) {
+	origMessages, origStore, origLimiter, origSecret := messages, messageStore, rateLimiter, rateLimitSecret
+	defer func() {
+		messages, messageStore, rateLimiter, rateLimitSecret = origMessages, origStore, origLimiter, origSecret
+	}()
+	messages = nil
+	rateLimitSecret = []byte("test-secret-not-for-production")
+
+	s, err := store.NewFileStore(filepath.Join(t.TempDir(), "guestbook.log"))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	defer s.Close()
+	messageStore = s
+
+	rateLimiter = ratelimit.NewMemoryLimiter()
+	defer rateLimiter.Close()
+
+	hub := NewHub()
+	hubStop := make(chan struct{})
+	go hub.Run(hubStop)
+	defer close(hubStop)
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, hub)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	pub, priv, err := mldsa65.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("mldsa65 keygen: %v", err)
+	}
+	pubBytes, err := pub.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal pubkey: %v", err)
+	}
+	pubB64 := base64.RawStdEncoding.EncodeToString(pubBytes)
+
+	post := func(content, ua string) {
+		ts := time.Now().UnixMilli()
+		canonical := canonicalPayload("backlogger", content, ts)
+		sig, err := priv.Sign(rand.Reader, canonical, crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		body, err := json.Marshal(Message{
+			Author:    "backlogger",
+			Content:   content,
+			Timestamp: ts,
+			Algo:      "mldsa65",
+			PubKey:    pubB64,
+			Signature: base64.RawStdEncoding.EncodeToString(sig),
+			UserAgent: ua,
+		})
+		if err != nil {
+			t.Fatalf("marshal message: %v", err)
+		}
+		resp, err := http.Post(server.URL+"/api/post", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST /api/post: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /api/post: status %d", resp.StatusCode)
+		}
+	}
+
+	// Big enough that, with nobody draining the socket yet, the handler's
+	// sendBlocking loop is still well short of done once wsSendBuffer fills.
+	const backlogSize = wsSendBuffer * 3
+	for i := 0; i < backlogSize; i++ {
+		post("backlog message", fmt.Sprintf("loss-test-seed-%d", i))
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/stream?since=1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial /api/stream: %v", err)
+	}
+	defer conn.Close()
+
+	// Post immediately, before reading anything: the handler's backlog loop
+	// is necessarily still stuck on the full send buffer at this point,
+	// which is exactly the window the fix closes.
+	post("live message", "loss-test-live")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var received []Message
+	for {
+		var m Message
+		if err := conn.ReadJSON(&m); err != nil {
+			t.Fatalf("reading from stream after %d messages: %v", len(received), err)
+		}
+		received = append(received, m)
+		if len(received) == backlogSize+1 {
+			break
+		}
+	}
+
+	if got := received[backlogSize].Content; got != "live message" {
+		t.Fatalf("message %d = %q, want the live post that landed mid-replay", backlogSize, got)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	mldsa65 "github.com/cloudflare/circl/sign/mldsa/mldsa65"
+)
+
+func hybridEnvelope(t *testing.T, canonical []byte, classicalOK, pqOK bool) (pubField, sigField string) {
+	t.Helper()
+
+	classicalPub, classicalPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519 keygen: %v", err)
+	}
+	pqPub, pqPriv, err := mldsa65.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("mldsa65 keygen: %v", err)
+	}
+
+	classicalSig := ed25519.Sign(classicalPriv, canonical)
+	if !classicalOK {
+		classicalSig[0] ^= 0xFF
+	}
+
+	pqSig, err := pqPriv.Sign(rand.Reader, canonical, crypto.Hash(0))
+	if err != nil {
+		t.Fatalf("mldsa65 sign: %v", err)
+	}
+	if !pqOK {
+		pqSig[0] ^= 0xFF
+	}
+
+	pqPubBytes, err := pqPub.MarshalBinary()
+	if err != nil {
+		t.Fatalf("mldsa65 marshal pubkey: %v", err)
+	}
+
+	pubField = base64.RawStdEncoding.EncodeToString(classicalPub) + "." + base64.RawStdEncoding.EncodeToString(pqPubBytes)
+	sigField = base64.RawStdEncoding.EncodeToString(classicalSig) + "." + base64.RawStdEncoding.EncodeToString(pqSig)
+	return pubField, sigField
+}
+
+func TestVerifyHybrid(t *testing.T) {
+	canonical := canonicalPayload("alice", "hello from the hybrid future", 1700000000000)
+
+	cases := []struct {
+		name        string
+		classicalOK bool
+		pqOK        bool
+		wantValid   bool
+	}{
+		{"both valid", true, true, true},
+		{"classical invalid", false, true, false},
+		{"pq invalid", true, false, false},
+		{"both invalid", false, false, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pubField, sigField := hybridEnvelope(t, canonical, tc.classicalOK, tc.pqOK)
+
+			valid, err := verifyHybrid("ed25519+mldsa65", pubField, sigField, canonical)
+			if err != nil {
+				t.Fatalf("verifyHybrid returned error: %v", err)
+			}
+			if valid != tc.wantValid {
+				t.Errorf("verifyHybrid = %v, want %v", valid, tc.wantValid)
+			}
+		})
+	}
+}
+
+func TestVerifyHybridMalformedEnvelope(t *testing.T) {
+	canonical := canonicalPayload("bob", "malformed envelope", 1700000000000)
+
+	if _, err := verifyHybrid("ed25519+mldsa65", "not-dot-joined", "also-not-dot-joined", canonical); err == nil {
+		t.Error("expected error for non dot-joined fields, got nil")
+	}
+
+	if _, err := verifyHybrid("ed25519+mldsa128", "a.b", "c.d", canonical); err == nil {
+		t.Error("expected error for unsupported hybrid level, got nil")
+	}
+}
+
+func TestVerifyMessagePureMLDSAStillWorks(t *testing.T) {
+	canonical := canonicalPayload("carol", "pure pq still works", 1700000000000)
+
+	pub, priv, err := mldsa65.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("mldsa65 keygen: %v", err)
+	}
+	sig, err := priv.Sign(rand.Reader, canonical, crypto.Hash(0))
+	if err != nil {
+		t.Fatalf("mldsa65 sign: %v", err)
+	}
+	pubBytes, err := pub.MarshalBinary()
+	if err != nil {
+		t.Fatalf("mldsa65 marshal pubkey: %v", err)
+	}
+
+	m := Message{
+		Author:    "carol",
+		Content:   "pure pq still works",
+		Timestamp: 1700000000000,
+		Algo:      "mldsa65",
+		PubKey:    base64.RawStdEncoding.EncodeToString(pubBytes),
+		Signature: base64.RawStdEncoding.EncodeToString(sig),
+	}
+
+	valid, err := verifyMessage(m, canonical)
+	if err != nil {
+		t.Fatalf("verifyMessage returned error: %v", err)
+	}
+	if !valid {
+		t.Error("verifyMessage = false, want true for a valid pure ML-DSA-65 message")
+	}
+}